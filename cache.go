@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// compressPromptTemplate is the un-interpolated instruction sent to every
+// provider; it is folded into the chunk cache key so changing the prompt
+// invalidates previously cached results.
+const compressPromptTemplate = "Compress this text fragment without losing important information: %s"
+
+// defaultCacheDir returns ~/.cache/ai-llmtxt (or the platform equivalent),
+// falling back to the system temp dir if the user cache dir can't be
+// determined.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "ai-llmtxt")
+}
+
+// ChunkCache is a content-addressed, on-disk cache of compressed chunk
+// output, keyed by sha256(model + prompt template + chunk content) so
+// re-running on the same input skips chunks that were already compressed.
+type ChunkCache struct {
+	dir string
+}
+
+// NewChunkCache returns a ChunkCache rooted at dir, creating it if needed.
+func NewChunkCache(dir string) (*ChunkCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %v", err)
+	}
+	return &ChunkCache{dir: dir}, nil
+}
+
+// Key derives the cache key for a chunk of content processed with model
+// against promptTemplate.
+func (c *ChunkCache) Key(model, promptTemplate, content string) string {
+	h := sha256.New()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(promptTemplate))
+	h.Write([]byte{0})
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *ChunkCache) path(key string) string {
+	return filepath.Join(c.dir, key+".txt")
+}
+
+// Get returns the cached result for key, if any.
+func (c *ChunkCache) Get(key string) (string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Put atomically stores result under key: it writes to a temp file in the
+// cache directory and renames it into place, so a crash mid-write can never
+// leave a corrupt cache entry behind.
+func (c *ChunkCache) Put(key, result string) error {
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating temp cache file: %v", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	if _, err := tmp.WriteString(result); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing cache entry: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing cache entry: %v", err)
+	}
+	if err := os.Rename(tmpPath, c.path(key)); err != nil {
+		return fmt.Errorf("error committing cache entry: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestChunkCacheKeyIsStableAndContentAddressed(t *testing.T) {
+	c := &ChunkCache{dir: t.TempDir()}
+
+	k1 := c.Key("model-a", "template %s", "hello")
+	k2 := c.Key("model-a", "template %s", "hello")
+	if k1 != k2 {
+		t.Errorf("Key() is not stable: %q != %q", k1, k2)
+	}
+
+	for _, tt := range []struct {
+		name, model, template, content string
+	}{
+		{"different model", "model-b", "template %s", "hello"},
+		{"different template", "model-a", "other %s", "hello"},
+		{"different content", "model-a", "template %s", "goodbye"},
+	} {
+		if got := c.Key(tt.model, tt.template, tt.content); got == k1 {
+			t.Errorf("%s: Key() collided with the base key %q", tt.name, k1)
+		}
+	}
+}
+
+func TestChunkCachePutGetRoundTrip(t *testing.T) {
+	c, err := NewChunkCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewChunkCache() error = %v", err)
+	}
+
+	key := c.Key("model", "template %s", "content")
+	if _, ok := c.Get(key); ok {
+		t.Fatal("Get() found a result before Put()")
+	}
+
+	if err := c.Put(key, "the result"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	got, ok := c.Get(key)
+	if !ok {
+		t.Fatal("Get() found nothing after Put()")
+	}
+	if got != "the result" {
+		t.Errorf("Get() = %q, want %q", got, "the result")
+	}
+}
+
+func TestChunkCacheGetMissingKey(t *testing.T) {
+	c := &ChunkCache{dir: t.TempDir()}
+	if _, ok := c.Get("no-such-key"); ok {
+		t.Error("Get() reported a hit for a key that was never Put")
+	}
+}
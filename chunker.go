@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// bytesPerToken is the rough heuristic used to estimate token counts without
+// a real tokenizer: about 4 characters per token for English-like text.
+const bytesPerToken = 4
+
+// Chunker splits a file's full content into the pieces sent to the
+// provider. The byte-size chunker is the tool's original strategy;
+// tokens and semantic are separate, context-window aware alternatives to
+// it for larger inputs.
+type Chunker interface {
+	Chunk(content string) []string
+}
+
+// NewChunker builds the Chunker named by name, returning an error for
+// unknown chunker names or out-of-range size/token/overlap values.
+func NewChunker(name string, byteSize, maxTokens, overlapTokens int) (Chunker, error) {
+	switch name {
+	case "bytes":
+		if byteSize <= 0 {
+			return nil, fmt.Errorf("chunk size must be positive, got %d", byteSize)
+		}
+		return &ByteChunker{size: byteSize}, nil
+	case "tokens":
+		if maxTokens <= 0 {
+			return nil, fmt.Errorf("-max-tokens must be positive, got %d", maxTokens)
+		}
+		if overlapTokens < 0 {
+			return nil, fmt.Errorf("-overlap must not be negative, got %d", overlapTokens)
+		}
+		return &TokenChunker{maxTokens: maxTokens, overlapTokens: overlapTokens}, nil
+	case "semantic":
+		if maxTokens <= 0 {
+			return nil, fmt.Errorf("-max-tokens must be positive, got %d", maxTokens)
+		}
+		if overlapTokens < 0 {
+			return nil, fmt.Errorf("-overlap must not be negative, got %d", overlapTokens)
+		}
+		return &SemanticChunker{maxTokens: maxTokens, overlapTokens: overlapTokens}, nil
+	default:
+		return nil, fmt.Errorf("unknown chunker %q (want bytes, tokens, or semantic)", name)
+	}
+}
+
+// ByteChunker splits content into fixed-size byte slices.
+type ByteChunker struct {
+	size int
+}
+
+func (c *ByteChunker) Chunk(content string) []string {
+	return splitIntoChunks(content, c.size)
+}
+
+// estimateTokens gives a cheap, tokenizer-free estimate of how many tokens a
+// string of text costs, using the rule of thumb in bytesPerToken.
+func estimateTokens(s string) int {
+	return (len(s) + bytesPerToken - 1) / bytesPerToken
+}
+
+// TokenChunker packs content into chunks that stay under an estimated
+// maxTokens budget, carrying overlapTokens of trailing content into the
+// next chunk so the model doesn't lose context at a chunk boundary.
+type TokenChunker struct {
+	maxTokens     int
+	overlapTokens int
+}
+
+func (c *TokenChunker) Chunk(content string) []string {
+	maxBytes := c.maxTokens * bytesPerToken
+	overlapBytes := c.overlapTokens * bytesPerToken
+	if maxBytes <= overlapBytes {
+		maxBytes = overlapBytes + bytesPerToken
+	}
+
+	var chunks []string
+	for start := 0; start < len(content); {
+		end := start + maxBytes
+		if end > len(content) {
+			end = len(content)
+		}
+		chunks = append(chunks, content[start:end])
+		if end == len(content) {
+			break
+		}
+		start = end - overlapBytes
+	}
+	return chunks
+}
+
+// semanticBoundary matches the points the SemanticChunker prefers to split
+// on: Markdown headings, blank lines between paragraphs, and sentence ends.
+var semanticBoundary = regexp.MustCompile(`(?m)(\n#{1,6} .*$|\n\s*\n|[.!?]\s+)`)
+
+// SemanticChunker groups content into chunks bounded by an estimated
+// maxTokens budget, preferring to break on Markdown headings, paragraph
+// breaks, or sentence boundaries instead of an arbitrary byte offset, with
+// overlapTokens of trailing context repeated into the next chunk.
+type SemanticChunker struct {
+	maxTokens     int
+	overlapTokens int
+}
+
+func (c *SemanticChunker) Chunk(content string) []string {
+	segments := splitOnSemanticBoundaries(content)
+	maxBytes := c.maxTokens * bytesPerToken
+	overlapBytes := c.overlapTokens * bytesPerToken
+
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		chunkText := current.String()
+		chunks = append(chunks, chunkText)
+
+		current.Reset()
+		if overlapBytes > 0 && len(chunkText) > overlapBytes {
+			current.WriteString(chunkText[len(chunkText)-overlapBytes:])
+		}
+	}
+
+	for _, seg := range segments {
+		if current.Len() > 0 && current.Len()+len(seg) > maxBytes {
+			flush()
+		}
+		current.WriteString(seg)
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// splitOnSemanticBoundaries breaks content right after each semanticBoundary
+// match, keeping the boundary text attached to the segment that precedes
+// it, so the segments can be reassembled losslessly with strings.Join.
+func splitOnSemanticBoundaries(content string) []string {
+	matches := semanticBoundary.FindAllStringIndex(content, -1)
+	if len(matches) == 0 {
+		return []string{content}
+	}
+
+	var segments []string
+	last := 0
+	for _, m := range matches {
+		segments = append(segments, content[last:m[1]])
+		last = m[1]
+	}
+	if last < len(content) {
+		segments = append(segments, content[last:])
+	}
+	return segments
+}
@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+func TestByteChunkerChunk(t *testing.T) {
+	c := &ByteChunker{size: 4}
+	got := c.Chunk("abcdefghij")
+	want := []string{"abcd", "efgh", "ij"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %q", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestByteChunkerChunkEmpty(t *testing.T) {
+	c := &ByteChunker{size: 4}
+	if got := c.Chunk(""); got != nil {
+		t.Errorf("Chunk(\"\") = %q, want nil", got)
+	}
+}
+
+func TestTokenChunkerOverlap(t *testing.T) {
+	// maxTokens=2, overlapTokens=1 -> maxBytes=8, overlapBytes=4.
+	c := &TokenChunker{maxTokens: 2, overlapTokens: 1}
+	content := "0123456789ABCDEF"
+	chunks := c.Chunk(content)
+	if len(chunks) < 2 {
+		t.Fatalf("expected at least 2 chunks, got %d: %q", len(chunks), chunks)
+	}
+	// Each chunk after the first should start with the trailing bytes of
+	// the previous chunk.
+	for i := 1; i < len(chunks); i++ {
+		prev, cur := chunks[i-1], chunks[i]
+		overlap := prev[len(prev)-4:]
+		if len(cur) < len(overlap) || cur[:len(overlap)] != overlap {
+			t.Errorf("chunk %d = %q does not start with overlap %q from chunk %d", i, cur, overlap, i-1)
+		}
+	}
+}
+
+func TestTokenChunkerNoOverlapCoversAllContent(t *testing.T) {
+	c := &TokenChunker{maxTokens: 3, overlapTokens: 0}
+	content := "The quick brown fox jumps over the lazy dog."
+	var rebuilt string
+	for _, chunk := range c.Chunk(content) {
+		rebuilt += chunk
+	}
+	if rebuilt != content {
+		t.Errorf("rejoined chunks = %q, want %q", rebuilt, content)
+	}
+}
+
+func TestSemanticChunkerRespectsMaxTokens(t *testing.T) {
+	c := &SemanticChunker{maxTokens: 5, overlapTokens: 0} // maxBytes = 20
+	content := "First sentence here. Second sentence here. Third sentence here."
+	for i, chunk := range c.Chunk(content) {
+		if len(chunk) > 40 { // generous slack: one segment may exceed maxBytes alone
+			t.Errorf("chunk %d is %d bytes, unexpectedly large: %q", i, len(chunk), chunk)
+		}
+	}
+}
+
+func TestNewChunkerUnknown(t *testing.T) {
+	if _, err := NewChunker("nonsense", 100, 100, 0); err == nil {
+		t.Error("expected an error for an unknown chunker name, got nil")
+	}
+}
+
+func TestNewChunkerRejectsOutOfRangeValues(t *testing.T) {
+	tests := []struct {
+		name                         string
+		byteSize, maxTokens, overlap int
+	}{
+		{"non-positive byte size", 0, 100, 0},
+		{"non-positive max tokens", 100, 0, 0},
+		{"negative max tokens", 100, -10, -5},
+		{"negative overlap", 100, 10, -5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, chunkerName := range []string{"bytes", "tokens", "semantic"} {
+				_, err := NewChunker(chunkerName, tt.byteSize, tt.maxTokens, tt.overlap)
+				wantErr := (chunkerName == "bytes" && tt.byteSize <= 0) ||
+					(chunkerName != "bytes" && (tt.maxTokens <= 0 || tt.overlap < 0))
+				if wantErr && err == nil {
+					t.Errorf("NewChunker(%q, %d, %d, %d) = nil error, want one", chunkerName, tt.byteSize, tt.maxTokens, tt.overlap)
+				}
+			}
+		})
+	}
+}
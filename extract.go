@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxToolIterations bounds the tool-call loop so a misbehaving model can't
+// keep the worker spinning forever.
+const maxToolIterations = 8
+
+// Tool names registered for extract mode.
+const (
+	toolEmitFact        = "emit_fact"
+	toolEmitEntity      = "emit_entity"
+	toolEmitCodeSnippet = "emit_code_snippet"
+)
+
+// extractPromptTemplate instructs the model to call the registered tools
+// instead of responding with prose; it is interpolated the same way
+// compressPromptTemplate is, and also used as the chunk cache template for
+// extract mode so switching modes doesn't serve stale compress results.
+const extractPromptTemplate = "Extract structured knowledge from this text fragment by calling the emit_fact, emit_entity, and emit_code_snippet tools as needed. Do not respond in prose. Text: %s"
+
+// ToolCaller is implemented by providers that support a tool-calling loop
+// for structured extraction. Only OllamaProvider implements it today.
+type ToolCaller interface {
+	ExtractFacts(ctx context.Context, model, content string) (string, error)
+}
+
+// toolDef and toolFunction mirror Ollama's (OpenAI-style) tool schema.
+type toolDef struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// extractionTools returns the registry of tools offered to the model in
+// extract mode.
+func extractionTools() []toolDef {
+	return []toolDef{
+		{Type: "function", Function: toolFunction{
+			Name:        toolEmitFact,
+			Description: "Record a subject-predicate-object fact extracted from the text.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"subject":   map[string]interface{}{"type": "string"},
+					"predicate": map[string]interface{}{"type": "string"},
+					"object":    map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"subject", "predicate", "object"},
+			},
+		}},
+		{Type: "function", Function: toolFunction{
+			Name:        toolEmitEntity,
+			Description: "Record a named entity and its type found in the text.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+					"type": map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"name", "type"},
+			},
+		}},
+		{Type: "function", Function: toolFunction{
+			Name:        toolEmitCodeSnippet,
+			Description: "Record a notable code snippet found in the text.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"lang": map[string]interface{}{"type": "string"},
+					"code": map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"lang", "code"},
+			},
+		}},
+	}
+}
+
+// ollamaChatMessage is one entry in a chat request/response, including the
+// tool-call fields the extract mode loop needs.
+type ollamaChatMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatToolResponse struct {
+	Message ollamaChatMessage `json:"message"`
+	Done    bool              `json:"done"`
+}
+
+// ExtractFacts runs the tool-call loop described in extractPromptTemplate:
+// it asks the model to call emit_fact/emit_entity/emit_code_snippet, feeds
+// each call's dispatch result back as a "tool" message, and repeats until
+// the model stops calling tools. The return value is the chunk's share of
+// the output as JSONL, one object per emitted fact/entity/snippet.
+func (p *OllamaProvider) ExtractFacts(ctx context.Context, model, content string) (string, error) {
+	messages := []ollamaChatMessage{
+		{Role: "user", Content: fmt.Sprintf(extractPromptTemplate, content)},
+	}
+
+	var jsonl strings.Builder
+	chatURL := strings.TrimRight(p.baseURL, "/") + "/chat"
+
+	for iteration := 0; iteration < maxToolIterations; iteration++ {
+		msg, err := p.chatWithTools(ctx, chatURL, model, messages)
+		if err != nil {
+			return "", err
+		}
+
+		if len(msg.ToolCalls) == 0 {
+			return jsonl.String(), nil
+		}
+
+		messages = append(messages, msg)
+		for _, call := range msg.ToolCalls {
+			line, err := dispatchTool(call)
+			if err != nil {
+				line = fmt.Sprintf(`{"type":"error","tool":%q,"error":%q}`, call.Function.Name, err.Error())
+			} else {
+				jsonl.WriteString(line)
+				jsonl.WriteString("\n")
+			}
+			messages = append(messages, ollamaChatMessage{Role: "tool", Content: line})
+		}
+	}
+
+	return jsonl.String(), nil
+}
+
+// chatWithTools sends one chat turn with the extraction tool registry
+// attached and returns the assistant's reply message.
+func (p *OllamaProvider) chatWithTools(ctx context.Context, chatURL, model string, messages []ollamaChatMessage) (ollamaChatMessage, error) {
+	requestBody, err := json.Marshal(struct {
+		Model    string              `json:"model"`
+		Messages []ollamaChatMessage `json:"messages"`
+		Tools    []toolDef           `json:"tools"`
+		Stream   bool                `json:"stream"`
+	}{
+		Model:    model,
+		Messages: messages,
+		Tools:    extractionTools(),
+		Stream:   false,
+	})
+	if err != nil {
+		return ollamaChatMessage{}, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, chatURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return ollamaChatMessage{}, fmt.Errorf("error building Ollama request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ollamaChatMessage{}, fmt.Errorf("error calling Ollama API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ollamaChatMessage{}, fmt.Errorf("error reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ollamaChatMessage{}, fmt.Errorf("API returned error status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var chatResp ollamaChatToolResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return ollamaChatMessage{}, fmt.Errorf("error parsing chat response: %v", err)
+	}
+	return chatResp.Message, nil
+}
+
+// extractFailureRecord renders a chunk that failed every retry attempt as a
+// single JSONL error record terminated by "\n", matching the convention
+// ExtractFacts's own output follows (separator is "" in extract mode, so
+// each record must end its own line rather than relying on a separator to
+// keep it from splicing into its neighbors).
+func extractFailureRecord(content string, processErr error) string {
+	line, err := json.Marshal(map[string]interface{}{
+		"type":  "error",
+		"error": processErr.Error(),
+	})
+	if err != nil {
+		return `{"type":"error","error":"chunk processing failed"}` + "\n"
+	}
+	return string(line) + "\n"
+}
+
+// dispatchTool turns one tool call into the JSONL line it contributes to
+// the extraction output. The "type" field and the call's arguments are
+// merged into one map and marshalled together, rather than string-splicing
+// JSON fragments, so a call with missing or empty arguments still produces
+// valid JSON.
+func dispatchTool(call ollamaToolCall) (string, error) {
+	switch call.Function.Name {
+	case toolEmitFact, toolEmitEntity, toolEmitCodeSnippet:
+		record := make(map[string]interface{}, len(call.Function.Arguments)+1)
+		for k, v := range call.Function.Arguments {
+			if k == "type" {
+				// emit_entity's own "type" argument would be overwritten by
+				// the record's "type" field below, so it is kept as
+				// "entity_type" instead.
+				k = "entity_type"
+			}
+			record[k] = v
+		}
+		record["type"] = strings.TrimPrefix(call.Function.Name, "emit_")
+
+		line, err := json.Marshal(record)
+		if err != nil {
+			return "", fmt.Errorf("error encoding arguments for %s: %v", call.Function.Name, err)
+		}
+		return string(line), nil
+	default:
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+}
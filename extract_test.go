@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDispatchToolProducesValidJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		call ollamaToolCall
+	}{
+		{
+			name: "fact with arguments",
+			call: ollamaToolCall{Function: struct {
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+			}{Name: toolEmitFact, Arguments: map[string]interface{}{
+				"subject": "Go", "predicate": "compiles to", "object": "native binaries",
+			}}},
+		},
+		{
+			name: "entity with nil arguments", // models often omit "arguments" entirely
+			call: ollamaToolCall{Function: struct {
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+			}{Name: toolEmitEntity, Arguments: nil}},
+		},
+		{
+			name: "snippet with empty arguments",
+			call: ollamaToolCall{Function: struct {
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+			}{Name: toolEmitCodeSnippet, Arguments: map[string]interface{}{}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := dispatchTool(tt.call)
+			if err != nil {
+				t.Fatalf("dispatchTool() error = %v", err)
+			}
+
+			var decoded map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+				t.Fatalf("dispatchTool() produced invalid JSON %q: %v", line, err)
+			}
+
+			wantType := tt.call.Function.Name[len("emit_"):]
+			if decoded["type"] != wantType {
+				t.Errorf("decoded[\"type\"] = %v, want %q", decoded["type"], wantType)
+			}
+		})
+	}
+}
+
+func TestDispatchToolUnknownTool(t *testing.T) {
+	call := ollamaToolCall{Function: struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}{Name: "emit_nonsense"}}
+
+	if _, err := dispatchTool(call); err == nil {
+		t.Error("expected an error for an unknown tool name, got nil")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// splitIntoChunks splits content into size-byte pieces. It backs
+// ByteChunker, so with -chunker=bytes it chunks both the original input file
+// and each prior level's joined output for the next recursive pass.
+func splitIntoChunks(content string, size int) []string {
+	if content == "" {
+		return nil
+	}
+
+	var chunks []string
+	for start := 0; start < len(content); start += size {
+		end := start + size
+		if end > len(content) {
+			end = len(content)
+		}
+		chunks = append(chunks, content[start:end])
+	}
+	return chunks
+}
+
+// writeLevelLog writes each chunk at this recursion level to its own file
+// under workDir/level-N/kind (kind is "input" for the chunks about to be
+// compressed, "output" for what came back), so a run leaves a tree-shaped
+// log to inspect, level by level and chunk by chunk. Nothing in the tool
+// reads these logs back: -resume works from the job manifest and the chunk
+// cache, not from workDir.
+func writeLevelLog(workDir string, level int, kind string, chunks []string) error {
+	levelDir := filepath.Join(workDir, fmt.Sprintf("level-%d", level), kind)
+	if err := os.MkdirAll(levelDir, 0755); err != nil {
+		return fmt.Errorf("error creating level directory: %v", err)
+	}
+
+	for i, chunk := range chunks {
+		path := filepath.Join(levelDir, fmt.Sprintf("chunk-%04d.txt", i))
+		if err := os.WriteFile(path, []byte(chunk), 0644); err != nil {
+			return fmt.Errorf("error writing chunk %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// stitchChunks runs a final reconciliation pass over the chunks produced by
+// the last recursion level, asking the model to resolve overlaps and smooth
+// section boundaries rather than naively joining them with strings.Join.
+func stitchChunks(provider Provider, model string, chunks []string) (string, error) {
+	var sections strings.Builder
+	for i, chunk := range chunks {
+		fmt.Fprintf(&sections, "--- Section %d ---\n%s\n", i+1, chunk)
+	}
+
+	prompt := fmt.Sprintf(
+		"The following sections are independently compressed pieces of one larger document, "+
+			"given in order. Reconcile any overlapping or duplicated content at the section "+
+			"boundaries and merge them into a single coherent document. Do not drop information "+
+			"found in any section.\n\n%s", sections.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout*time.Second)
+	defer cancel()
+
+	return provider.Compress(ctx, model, prompt)
+}
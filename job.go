@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ChunkState records one chunk's cache key, outcome, and (for a completed
+// chunk) its result in a job manifest. Content is what lets -resume
+// reconstruct a run on its own: it is seeded back into the chunk cache
+// before reprocessing, so resuming doesn't depend on -cache-dir still
+// pointing at the same, non-cleared cache the original run used.
+type ChunkState struct {
+	Index   int    `json:"index"`
+	Hash    string `json:"hash"`
+	Status  string `json:"status"` // "pending", "done", or "error"
+	Content string `json:"content,omitempty"`
+}
+
+// JobManifest captures enough state about one run to resume it: which
+// provider/model/chunker produced the chunks, and each chunk's cache key,
+// outcome, and result, so -resume can reseed the cache and skip completed
+// chunks even if -cache-dir was cleared or points elsewhere.
+type JobManifest struct {
+	InputFile string       `json:"input_file"`
+	InputHash string       `json:"input_hash"`
+	Provider  string       `json:"provider"`
+	Model     string       `json:"model"`
+	Chunker   string       `json:"chunker"`
+	Chunks    []ChunkState `json:"chunks"`
+}
+
+// hashContent returns the sha256 hex digest of content, used to confirm a
+// resumed job still matches the input it was created against.
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveJobManifest atomically writes m to path.
+func SaveJobManifest(path string, m *JobManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding job manifest: %v", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing job manifest: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error committing job manifest: %v", err)
+	}
+	return nil
+}
+
+// LoadJobManifest reads a manifest previously written by SaveJobManifest.
+func LoadJobManifest(path string) (*JobManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading job manifest: %v", err)
+	}
+	var m JobManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing job manifest: %v", err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestJobManifestSaveLoadRoundTrip(t *testing.T) {
+	m := &JobManifest{
+		InputFile: "input.txt",
+		InputHash: hashContent("input.txt"),
+		Provider:  "ollama",
+		Model:     "llama3.2-vision:latest",
+		Chunker:   "bytes",
+		Chunks: []ChunkState{
+			{Index: 0, Hash: "abc", Status: "done", Content: "compressed chunk 0"},
+			{Index: 1, Hash: "def", Status: "error"},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "job.json")
+	if err := SaveJobManifest(path, m); err != nil {
+		t.Fatalf("SaveJobManifest() error = %v", err)
+	}
+
+	loaded, err := LoadJobManifest(path)
+	if err != nil {
+		t.Fatalf("LoadJobManifest() error = %v", err)
+	}
+
+	if loaded.InputHash != m.InputHash || loaded.Provider != m.Provider || loaded.Model != m.Model || loaded.Chunker != m.Chunker {
+		t.Errorf("LoadJobManifest() = %+v, want top-level fields matching %+v", loaded, m)
+	}
+	if len(loaded.Chunks) != len(m.Chunks) {
+		t.Fatalf("LoadJobManifest() returned %d chunks, want %d", len(loaded.Chunks), len(m.Chunks))
+	}
+	for i := range m.Chunks {
+		if loaded.Chunks[i] != m.Chunks[i] {
+			t.Errorf("chunk %d = %+v, want %+v", i, loaded.Chunks[i], m.Chunks[i])
+		}
+	}
+}
+
+func TestLoadJobManifestMissingFile(t *testing.T) {
+	if _, err := LoadJobManifest(filepath.Join(t.TempDir(), "does-not-exist.json")); err == nil {
+		t.Error("expected an error loading a manifest that doesn't exist, got nil")
+	}
+}
+
+func TestHashContentIsStableAndContentAddressed(t *testing.T) {
+	if hashContent("same") != hashContent("same") {
+		t.Error("hashContent() is not stable across identical input")
+	}
+	if hashContent("a") == hashContent("b") {
+		t.Error("hashContent() collided for different input")
+	}
+}
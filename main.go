@@ -1,39 +1,19 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/schollz/progressbar/v3"
 )
 
-// Different Ollama API response formats
-type OllamaGenerateResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
-}
-
-type OllamaCompletionResponse struct {
-	Model     string `json:"model"`
-	CreatedAt string `json:"created_at"`
-	Message   struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	} `json:"message"`
-	Done bool `json:"done"`
-}
-
 // ChunkResult stores the result of a processed chunk
 type ChunkResult struct {
 	Index   int
@@ -44,7 +24,7 @@ type ChunkResult struct {
 const (
 	// Maximum chunk size (in bytes) for processing
 	chunkSize = 4000
-	// Maximum concurrent requests to Ollama
+	// Maximum concurrent requests to the provider
 	maxConcurrentRequests = 3
 	// Timeout for HTTP requests (in seconds)
 	requestTimeout = 120
@@ -57,12 +37,34 @@ const (
 func main() {
 	// Define command line flags
 	inputFile := flag.String("input", "", "Path to the input file (required)")
-	modelName := flag.String("model", "llama3.2-vision:latest", "Ollama model name to use")
+	modelName := flag.String("model", "llama3.2-vision:latest", "Model name to use")
 	outputFile := flag.String("output", "llm.txt", "Output file name")
-	apiEndpoint := flag.String("api", "generate", "Ollama API endpoint: 'generate' or 'chat'")
+	apiEndpoint := flag.String("api", "generate", "Ollama API endpoint: 'generate' or 'chat' (ollama provider only)")
 	ollamaBaseURL := flag.String("url", "http://localhost:11434/api", "Ollama API base URL")
+	providerName := flag.String("provider", "ollama", "Backend provider: ollama, openai, anthropic, or google")
+	apiKey := flag.String("api-key", "", "API key for hosted providers (openai, anthropic, google); defaults to $AI_LLMTXT_API_KEY")
+	openAIBaseURL := flag.String("openai-url", "https://api.openai.com", "OpenAI-compatible API base URL")
+	anthropicBaseURL := flag.String("anthropic-url", "https://api.anthropic.com", "Anthropic API base URL")
+	googleBaseURL := flag.String("google-url", "https://generativelanguage.googleapis.com", "Google Gemini API base URL")
+	levels := flag.Int("levels", 1, "Number of recursive compression passes; >1 re-chunks and re-compresses the prior level's output")
+	targetSize := flag.Int64("target-size", 0, "Stop recursing once the combined output is <= this many bytes (0 disables)")
+	workDir := flag.String("work-dir", "", "Optional directory to write per-level intermediate chunk logs")
+	chunkerName := flag.String("chunker", "bytes", "Chunking strategy: bytes, tokens, or semantic")
+	maxTokens := flag.Int("max-tokens", 1000, "Target chunk size in estimated tokens (tokens/semantic chunkers)")
+	overlap := flag.Int("overlap", 0, "Tokens of trailing context carried into the next chunk (tokens/semantic chunkers)")
+	cacheDir := flag.String("cache-dir", defaultCacheDir(), "Directory for the content-addressed chunk cache")
+	resumeJob := flag.String("resume", "", "Path to a previous job.json manifest to resume from; reseeds the chunk cache with its stored results")
+	mode := flag.String("mode", "compress", "Processing mode: compress (free-form compression) or extract (tool-calling structured extraction)")
 	flag.Parse()
 
+	// Read the API key from the environment after parsing, rather than
+	// wiring it through the flag's default: flag.Usage() prints every
+	// flag's default value, and it's called automatically on a bare or
+	// malformed invocation, which would otherwise dump a live key to stderr.
+	if *apiKey == "" {
+		*apiKey = os.Getenv("AI_LLMTXT_API_KEY")
+	}
+
 	// Check if input file was provided
 	if *inputFile == "" {
 		fmt.Println("Error: Input file is required")
@@ -70,13 +72,19 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Validate API endpoint
-	if *apiEndpoint != "generate" && *apiEndpoint != "chat" {
+	// Validate API endpoint (only meaningful for the ollama provider)
+	if *providerName == "ollama" && *apiEndpoint != "generate" && *apiEndpoint != "chat" {
 		fmt.Println("Error: API endpoint must be 'generate' or 'chat'")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if *mode != "compress" && *mode != "extract" {
+		fmt.Println("Error: mode must be 'compress' or 'extract'")
+		flag.Usage()
+		os.Exit(1)
+	}
+
 	// Get file info
 	fileInfo, err := os.Stat(*inputFile)
 	if err != nil {
@@ -85,61 +93,113 @@ func main() {
 	fileSize := fileInfo.Size()
 
 	fmt.Printf("Processing file: %s (%.2f MB)\n", *inputFile, float64(fileSize)/1024/1024)
-	fmt.Printf("Using Ollama model: %s\n", *modelName)
-	fmt.Printf("Using API endpoint: %s\n", *apiEndpoint)
+	fmt.Printf("Using provider: %s, model: %s\n", *providerName, *modelName)
 
-	// Construct full API URL
+	// Construct full Ollama API URL (only used by the ollama provider)
 	ollamaURL := fmt.Sprintf("%s/%s", *ollamaBaseURL, *apiEndpoint)
 
-	// Open the input file
-	file, err := os.Open(*inputFile)
+	// Create a client with timeout, shared by every provider
+	client := &http.Client{
+		Timeout: time.Duration(requestTimeout) * time.Second,
+	}
+
+	provider, err := NewProvider(*providerName, providerConfig{
+		client:       client,
+		apiKey:       *apiKey,
+		ollamaBase:   *ollamaBaseURL,
+		ollamaURL:    ollamaURL,
+		apiEndpoint:  *apiEndpoint,
+		openAIURL:    *openAIBaseURL,
+		anthropicURL: *anthropicBaseURL,
+		googleURL:    *googleBaseURL,
+	})
 	if err != nil {
-		log.Fatalf("Error opening file: %v", err)
+		log.Fatalf("Error configuring provider: %v", err)
 	}
-	defer file.Close()
 
-	// Calculate total chunks
-	totalChunks := (int(fileSize) + chunkSize - 1) / chunkSize
-	fmt.Printf("Splitting file into %d chunks\n", totalChunks)
+	// Select the per-chunk processor and its cache template for the chosen
+	// mode. Extract mode requires a provider that implements ToolCaller.
+	var process chunkProcessor
+	cacheTemplate := compressPromptTemplate
+	if *mode == "extract" {
+		toolCaller, ok := provider.(ToolCaller)
+		if !ok {
+			log.Fatalf("Error: the %s provider does not support -mode=extract", *providerName)
+		}
+		process = toolCaller.ExtractFacts
+		cacheTemplate = extractPromptTemplate
+	} else {
+		process = func(ctx context.Context, model, content string) (string, error) {
+			return provider.Compress(ctx, model, buildCompressPrompt(content))
+		}
+	}
 
-	// Create a progress bar for reading and chunking
-	bar := progressbar.NewOptions(totalChunks,
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetWidth(50),
-		progressbar.OptionSetDescription("[cyan]Chunking file[reset]"),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]=[reset]",
-			SaucerHead:    "[green]>[reset]",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}))
+	chunker, err := NewChunker(*chunkerName, chunkSize, *maxTokens, *overlap)
+	if err != nil {
+		log.Fatalf("Error configuring chunker: %v", err)
+	}
 
-	// Split file into chunks
-	chunks := make([]string, 0, totalChunks)
-	reader := bufio.NewReader(file)
-	for {
-		chunk := make([]byte, chunkSize)
-		n, err := reader.Read(chunk)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("Error reading chunk: %v", err)
+	// Read the whole file: the tokens and semantic chunkers need to look
+	// ahead across the content to find good split points.
+	content, err := os.ReadFile(*inputFile)
+	if err != nil {
+		log.Fatalf("Error reading input file: %v", err)
+	}
+
+	chunks := chunker.Chunk(string(content))
+	fmt.Printf("Split file into %d chunks using the %s chunker\n", len(chunks), *chunkerName)
+	if *chunkerName != "bytes" {
+		fmt.Printf("Estimated input size: ~%d tokens\n", estimateTokens(string(content)))
+	}
+
+	if *workDir != "" {
+		if err := writeLevelLog(*workDir, 1, "input", chunks); err != nil {
+			log.Printf("Warning: failed writing level 1 input log to %s: %v", *workDir, err)
 		}
+	}
+
+	cache, err := NewChunkCache(*cacheDir)
+	if err != nil {
+		log.Fatalf("Error setting up chunk cache: %v", err)
+	}
 
-		// Only include what was actually read
-		chunks = append(chunks, string(chunk[:n]))
-		bar.Add(1)
+	inputHash := hashContent(string(content))
+	jobPath := *resumeJob
+	if jobPath == "" {
+		jobPath = *outputFile + ".job.json"
+	}
+	if *resumeJob != "" {
+		if prev, err := LoadJobManifest(*resumeJob); err != nil {
+			log.Printf("Warning: could not load resume manifest %s: %v", *resumeJob, err)
+		} else if prev.InputHash != inputHash {
+			log.Printf("Warning: resume manifest %s was built from a different input; only matching cached chunks will be reused", *resumeJob)
+		} else {
+			// Reseed the chunk cache from the manifest's own stored results,
+			// so resuming works even if -cache-dir was cleared or points
+			// somewhere other than where the original run wrote to.
+			done := 0
+			for _, cs := range prev.Chunks {
+				if cs.Status != "done" {
+					continue
+				}
+				done++
+				if cs.Content == "" {
+					continue
+				}
+				if err := cache.Put(cs.Hash, cs.Content); err != nil {
+					log.Printf("Warning: failed to reseed cache for chunk %d: %v", cs.Index, err)
+				}
+			}
+			fmt.Printf("Resuming job %s: %d/%d chunks previously completed\n", *resumeJob, done, len(prev.Chunks))
+		}
 	}
 
-	// Create a second progress bar for processing chunks
+	// Create a progress bar for processing chunks
 	processBar := progressbar.NewOptions(len(chunks),
 		progressbar.OptionEnableColorCodes(true),
 		progressbar.OptionShowCount(),
 		progressbar.OptionSetWidth(50),
-		progressbar.OptionSetDescription("[cyan]Processing with Ollama[reset]"),
+		progressbar.OptionSetDescription("[cyan]Processing chunks[reset]"),
 		progressbar.OptionSetTheme(progressbar.Theme{
 			Saucer:        "[green]=[reset]",
 			SaucerHead:    "[green]>[reset]",
@@ -148,90 +208,138 @@ func main() {
 			BarEnd:        "]",
 		}))
 
-	// Create a client with timeout
-	client := &http.Client{
-		Timeout: time.Duration(requestTimeout) * time.Second,
+	separator := "\n\n"
+	if *mode == "extract" {
+		separator = ""
+	}
+
+	// Recursion (compress mode with -levels > 1) needs the full joined
+	// output in memory to re-chunk it for the next level, so only stream
+	// straight to the destination when there's exactly one level.
+	streaming := *mode == "extract" || *levels <= 1
+
+	var out io.WriteCloser
+	var writer *streamWriter
+	if streaming {
+		out, err = openOutput(*outputFile)
+		if err != nil {
+			log.Fatalf("Error opening output: %v", err)
+		}
+		defer out.Close()
+		writer = newStreamWriter(out, separator)
 	}
 
-	// Set up concurrency control
-	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, maxConcurrentRequests)
-	results := make(chan ChunkResult, len(chunks))
+	// Extract mode's output is JSONL with no inter-chunk separator, so a
+	// chunk that fails every retry can't fall back to its raw original text
+	// (compress mode's fallback) without splicing prose between
+	// otherwise-valid JSON lines.
+	onFailure := func(content string, err error) string { return content }
+	if *mode == "extract" {
+		onFailure = extractFailureRecord
+	}
 
-	// Process chunks concurrently
-	for i, chunk := range chunks {
-		wg.Add(1)
-		semaphore <- struct{}{} // Acquire semaphore
+	processedChunks, errorCount, chunkStates := compressChunks(process, cacheTemplate, *modelName, chunks, processBar, cache, writer, onFailure)
 
-		go func(index int, content string) {
-			defer wg.Done()
-			defer func() { <-semaphore }() // Release semaphore
+	if *workDir != "" {
+		if err := writeLevelLog(*workDir, 1, "output", processedChunks); err != nil {
+			log.Printf("Warning: failed writing level 1 output log to %s: %v", *workDir, err)
+		}
+	}
 
-			var result string
-			var processErr error
+	manifest := &JobManifest{
+		InputFile: *inputFile,
+		InputHash: inputHash,
+		Provider:  *providerName,
+		Model:     *modelName,
+		Chunker:   *chunkerName,
+		Chunks:    chunkStates,
+	}
+	if err := SaveJobManifest(jobPath, manifest); err != nil {
+		log.Printf("Warning: failed to write job manifest: %v", err)
+	} else {
+		fmt.Printf("Job manifest written to %s\n", jobPath)
+	}
 
-			// Try processing with retries
-			for attempt := 0; attempt < maxRetries; attempt++ {
-				// If this is a retry, wait before trying again
-				if attempt > 0 {
-					time.Sleep(time.Duration(retryDelay) * time.Second)
-				}
+	// Level 1 is the flat processing pass above; -levels > 1 recursively
+	// re-chunks and re-compresses the prior level's output. Recursion only
+	// applies to compress mode, since extract mode's JSONL output isn't
+	// meant to be re-compressed as prose.
+	finalChunks := processedChunks
+	level := 1
+	for *mode == "compress" && *levels > 1 && level < *levels {
+		combined := strings.Join(finalChunks, "\n\n")
+		if *targetSize > 0 && int64(len(combined)) <= *targetSize {
+			fmt.Printf("Target size reached after level %d (%d bytes)\n", level, len(combined))
+			break
+		}
 
-				result, processErr = processChunk(client, ollamaURL, *modelName, content, *apiEndpoint)
-				if processErr == nil {
-					break
-				}
+		level++
+		nextChunks := chunker.Chunk(combined)
+		fmt.Printf("Level %d: re-chunked prior output into %d chunks using the %s chunker\n", level, len(nextChunks), *chunkerName)
 
-				log.Printf("Attempt %d: Error processing chunk %d: %v", attempt+1, index, processErr)
+		if *workDir != "" {
+			if err := writeLevelLog(*workDir, level, "input", nextChunks); err != nil {
+				log.Printf("Warning: failed writing level %d input log to %s: %v", level, *workDir, err)
 			}
+		}
 
-			if processErr != nil {
-				log.Printf("All attempts failed for chunk %d: %v", index, processErr)
-				results <- ChunkResult{Index: index, Content: content, Error: processErr} // Use original on error
-			} else {
-				results <- ChunkResult{Index: index, Content: result, Error: nil}
+		levelBar := progressbar.NewOptions(len(nextChunks),
+			progressbar.OptionEnableColorCodes(true),
+			progressbar.OptionShowCount(),
+			progressbar.OptionSetWidth(50),
+			progressbar.OptionSetDescription(fmt.Sprintf("[cyan]Level %d compression[reset]", level)),
+			progressbar.OptionSetTheme(progressbar.Theme{
+				Saucer:        "[green]=[reset]",
+				SaucerHead:    "[green]>[reset]",
+				SaucerPadding: " ",
+				BarStart:      "[",
+				BarEnd:        "]",
+			}))
+
+		var levelErrors int
+		finalChunks, levelErrors, _ = compressChunks(process, cacheTemplate, *modelName, nextChunks, levelBar, cache, nil, onFailure)
+		errorCount += levelErrors
+
+		if *workDir != "" {
+			if err := writeLevelLog(*workDir, level, "output", finalChunks); err != nil {
+				log.Printf("Warning: failed writing level %d output log to %s: %v", level, *workDir, err)
 			}
-
-			processBar.Add(1)
-		}(i, chunk)
+		}
 	}
 
-	// Close results channel when all goroutines are done
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// Collect results
-	processedChunks := make([]string, len(chunks))
-	errorCount := 0
-
-	for result := range results {
-		if result.Error != nil {
-			errorCount++
-			// Use original content on error
-			processedChunks[result.Index] = result.Content
+	var outputBytes int64
+	if streaming {
+		// Already flushed to *outputFile (or stdout) chunk by chunk as
+		// results arrived; nothing left to combine or write.
+		cps, bps := writer.Throughput()
+		fmt.Printf("\nThroughput: %.1f chunks/sec, %s\n", cps, formatRate(bps))
+		outputBytes = writer.bytesWritten
+	} else {
+		// Recursion needs the full joined output in memory; combine it here,
+		// reconciling overlaps with a dedicated stitching pass when at least
+		// one recursive level ran and more than one final-level chunk remains.
+		var combinedContent string
+		if level > 1 && len(finalChunks) > 1 {
+			stitched, err := stitchChunks(provider, *modelName, finalChunks)
+			if err != nil {
+				log.Printf("Warning: stitching pass failed, falling back to joined output: %v", err)
+				combinedContent = strings.Join(finalChunks, "\n\n")
+			} else {
+				combinedContent = stitched
+			}
 		} else {
-			processedChunks[result.Index] = result.Content
+			combinedContent = strings.Join(finalChunks, "\n\n")
 		}
-	}
 
-	// Combine processed chunks
-	combinedContent := strings.Join(processedChunks, "\n\n")
-
-	// Ensure output directory exists
-	outputDir := filepath.Dir(*outputFile)
-	if outputDir != "." {
-		err = os.MkdirAll(outputDir, 0755)
+		out, err := openOutput(*outputFile)
 		if err != nil {
-			log.Fatalf("Error creating output directory: %v", err)
+			log.Fatalf("Error opening output: %v", err)
 		}
-	}
-
-	// Write the compressed content to the output file
-	err = os.WriteFile(*outputFile, []byte(combinedContent), 0644)
-	if err != nil {
-		log.Fatalf("Error writing to output file: %v", err)
+		if _, err := io.WriteString(out, combinedContent); err != nil {
+			log.Fatalf("Error writing to output file: %v", err)
+		}
+		out.Close()
+		outputBytes = int64(len(combinedContent))
 	}
 
 	fmt.Printf("\nCompression complete: %d of %d chunks processed successfully (%d errors)\n",
@@ -239,114 +347,17 @@ func main() {
 	fmt.Printf("Output saved to %s\n", *outputFile)
 
 	// Calculate compression ratio
-	outputInfo, err := os.Stat(*outputFile)
-	if err == nil {
-		compressionRatio := float64(fileSize) / float64(outputInfo.Size())
+	if *outputFile != "-" && outputBytes > 0 {
+		compressionRatio := float64(fileSize) / float64(outputBytes)
 		fmt.Printf("Compression ratio: %.2fx (from %.2f MB to %.2f MB)\n",
 			compressionRatio,
 			float64(fileSize)/1024/1024,
-			float64(outputInfo.Size())/1024/1024)
+			float64(outputBytes)/1024/1024)
 	}
 }
 
-// processChunk handles processing a single chunk with the appropriate API
-func processChunk(client *http.Client, ollamaURL, modelName, content, apiEndpoint string) (string, error) {
-	var requestBody []byte
-	var err error
-
-	// Create request based on API endpoint
-	if apiEndpoint == "generate" {
-		// For generate API
-		generateRequest := struct {
-			Model  string `json:"model"`
-			Prompt string `json:"prompt"`
-		}{
-			Model:  modelName,
-			Prompt: fmt.Sprintf("Compress this text fragment without losing important information: %s", content),
-		}
-		requestBody, err = json.Marshal(generateRequest)
-	} else {
-		// For chat API
-		chatRequest := struct {
-			Model    string `json:"model"`
-			Messages []struct {
-				Role    string `json:"role"`
-				Content string `json:"content"`
-			} `json:"messages"`
-		}{
-			Model: modelName,
-			Messages: []struct {
-				Role    string `json:"role"`
-				Content string `json:"content"`
-			}{
-				{
-					Role:    "user",
-					Content: fmt.Sprintf("Compress this text fragment without losing important information: %s", content),
-				},
-			},
-		}
-		requestBody, err = json.Marshal(chatRequest)
-	}
-
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %v", err)
-	}
-
-	// Send request to Ollama
-	resp, err := client.Post(ollamaURL, "application/json", bytes.NewBuffer(requestBody))
-	if err != nil {
-		return "", fmt.Errorf("error calling Ollama API: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API returned error status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Process response based on API endpoint
-	if apiEndpoint == "generate" {
-		// Handle streaming response for generate endpoint
-		var result strings.Builder
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := scanner.Bytes()
-			var generateResp OllamaGenerateResponse
-			if err := json.Unmarshal(line, &generateResp); err != nil {
-				return "", fmt.Errorf("error parsing streaming response line: %v", err)
-			}
-			result.WriteString(generateResp.Response)
-			if generateResp.Done {
-				break
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			return "", fmt.Errorf("error reading streaming response: %v", err)
-		}
-		return result.String(), nil
-	} else {
-		// Handle single response for chat endpoint
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("error reading response: %v", err)
-		}
-
-		var chatResp OllamaCompletionResponse
-		if err := json.Unmarshal(body, &chatResp); err == nil && chatResp.Message.Content != "" {
-			return chatResp.Message.Content, nil
-		}
-
-		// Fallback parsing for unexpected format
-		var result map[string]interface{}
-		if err := json.Unmarshal(body, &result); err != nil {
-			return "", fmt.Errorf("error parsing chat response: %v", err)
-		}
-		if message, ok := result["message"].(map[string]interface{}); ok {
-			if content, ok := message["content"].(string); ok {
-				return content, nil
-			}
-		}
-		return "", fmt.Errorf("could not extract content from chat API response: %s", string(body))
-	}
+// buildCompressPrompt wraps a chunk's content in the instruction sent to
+// every provider, keeping the wording identical across backends.
+func buildCompressPrompt(content string) string {
+	return fmt.Sprintf(compressPromptTemplate, content)
 }
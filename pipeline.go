@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// chunkProcessor processes one chunk's content and returns the model's
+// output for it; compress mode wraps provider.Compress with
+// buildCompressPrompt, extract mode calls a ToolCaller's ExtractFacts.
+type chunkProcessor func(ctx context.Context, model, content string) (string, error)
+
+// compressChunks runs chunks through process concurrently. The semaphore
+// bounds how many chunks are in flight at once, which doubles as
+// backpressure between chunk dispatch and the workers calling out to the
+// provider. Each chunk gets up to maxRetries attempts in all. It returns the
+// results in the original chunk order, a count of chunks that failed every
+// attempt, and the per-chunk cache state for a job manifest. cacheTemplate
+// identifies the processing mode in the cache key so switching modes can't
+// serve stale results. cache may be nil to disable caching; bar may be nil
+// to disable progress reporting. When writer is non-nil, each result is also
+// pushed to it as soon as it completes, so in-order output can be flushed to
+// disk before every chunk is done rather than only after wg.Wait() returns.
+// onFailure renders the fallback content for a chunk that failed every
+// retry; compress mode passes the chunk's own original text through
+// unchanged, while extract mode needs a well-formed JSONL record instead of
+// raw prose, so it supplies its own.
+func compressChunks(process chunkProcessor, cacheTemplate, model string, chunks []string, bar *progressbar.ProgressBar, cache *ChunkCache, writer *streamWriter, onFailure func(content string, err error) string) ([]string, int, []ChunkState) {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxConcurrentRequests)
+	results := make(chan ChunkResult, len(chunks))
+	states := make([]ChunkState, len(chunks))
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		semaphore <- struct{}{} // Acquire semaphore
+
+		go func(index int, content string) {
+			defer wg.Done()
+			defer func() { <-semaphore }() // Release semaphore
+
+			var cacheKey string
+			if cache != nil {
+				cacheKey = cache.Key(model, cacheTemplate, content)
+				states[index] = ChunkState{Index: index, Hash: cacheKey}
+
+				if cached, ok := cache.Get(cacheKey); ok {
+					states[index].Status = "done"
+					states[index].Content = cached
+					results <- ChunkResult{Index: index, Content: cached, Error: nil}
+					if bar != nil {
+						bar.Add(1)
+					}
+					return
+				}
+			}
+
+			var result string
+			var processErr error
+
+			// Try processing with retries
+			for attempt := 0; attempt < maxRetries; attempt++ {
+				// If this is a retry, wait before trying again
+				if attempt > 0 {
+					time.Sleep(time.Duration(retryDelay) * time.Second)
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), requestTimeout*time.Second)
+				result, processErr = process(ctx, model, content)
+				cancel()
+				if processErr == nil {
+					break
+				}
+
+				log.Printf("Attempt %d: Error processing chunk %d: %v", attempt+1, index, processErr)
+			}
+
+			if processErr != nil {
+				log.Printf("All attempts failed for chunk %d: %v", index, processErr)
+				states[index].Status = "error"
+				results <- ChunkResult{Index: index, Content: onFailure(content, processErr), Error: processErr}
+			} else {
+				states[index].Status = "done"
+				states[index].Content = result
+				if cache != nil {
+					if err := cache.Put(cacheKey, result); err != nil {
+						log.Printf("Warning: failed to cache chunk %d: %v", index, err)
+					}
+				}
+				results <- ChunkResult{Index: index, Content: result, Error: nil}
+			}
+
+			if bar != nil {
+				bar.Add(1)
+			}
+		}(i, chunk)
+	}
+
+	// Close results channel when all goroutines are done
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Collect results
+	processedChunks := make([]string, len(chunks))
+	errorCount := 0
+
+	for result := range results {
+		if result.Error != nil {
+			errorCount++
+		}
+		// On error, Content holds onFailure's fallback for the chunk
+		processedChunks[result.Index] = result.Content
+
+		if writer != nil {
+			if err := writer.Push(result.Index, result.Content); err != nil {
+				log.Printf("Warning: failed writing chunk %d: %v", result.Index, err)
+			} else if bar != nil {
+				cps, bps := writer.Throughput()
+				bar.Describe(fmt.Sprintf("[cyan]Processing chunks (%.1f/s, %s)[reset]", cps, formatRate(bps)))
+			}
+		}
+	}
+
+	return processedChunks, errorCount, states
+}
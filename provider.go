@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Different Ollama API response formats
+type OllamaGenerateResponse struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+}
+
+type OllamaCompletionResponse struct {
+	Model     string `json:"model"`
+	CreatedAt string `json:"created_at"`
+	Message   struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	Done bool `json:"done"`
+}
+
+// Provider abstracts over the various chat/completion backends that can be
+// used to compress a chunk of text. Implementations are responsible for
+// building the request payload, calling the remote API, and extracting the
+// generated text, but share the retry/timeout plumbing in main's worker loop.
+type Provider interface {
+	// Compress sends prompt (with content already interpolated) to the
+	// backend and returns the model's response text.
+	Compress(ctx context.Context, model, prompt string) (string, error)
+}
+
+// providerConfig collects the flag-derived settings needed to construct any
+// Provider. Not every field is used by every provider.
+type providerConfig struct {
+	client       *http.Client
+	apiKey       string
+	ollamaBase   string // Ollama API base URL, e.g. http://localhost:11434/api
+	ollamaURL    string // full Ollama endpoint URL (base + /generate or /chat)
+	apiEndpoint  string // "generate" or "chat", Ollama only
+	openAIURL    string
+	anthropicURL string
+	googleURL    string
+}
+
+// NewProvider builds the Provider named by name, returning an error for
+// unknown provider names.
+func NewProvider(name string, cfg providerConfig) (Provider, error) {
+	switch name {
+	case "ollama":
+		return &OllamaProvider{client: cfg.client, baseURL: cfg.ollamaBase, url: cfg.ollamaURL, apiEndpoint: cfg.apiEndpoint}, nil
+	case "openai":
+		return &OpenAIProvider{client: cfg.client, baseURL: cfg.openAIURL, apiKey: cfg.apiKey}, nil
+	case "anthropic":
+		return &AnthropicProvider{client: cfg.client, baseURL: cfg.anthropicURL, apiKey: cfg.apiKey}, nil
+	case "google":
+		return &GoogleProvider{client: cfg.client, baseURL: cfg.googleURL, apiKey: cfg.apiKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want ollama, openai, anthropic, or google)", name)
+	}
+}
+
+// OllamaProvider talks to a local (or remote) Ollama server using either the
+// "generate" or "chat" endpoint, matching the behavior of the original
+// single-backend implementation.
+type OllamaProvider struct {
+	client      *http.Client
+	baseURL     string
+	url         string
+	apiEndpoint string
+}
+
+func (p *OllamaProvider) Compress(ctx context.Context, model, prompt string) (string, error) {
+	var requestBody []byte
+	var err error
+
+	if p.apiEndpoint == "generate" {
+		generateRequest := struct {
+			Model  string `json:"model"`
+			Prompt string `json:"prompt"`
+		}{
+			Model:  model,
+			Prompt: prompt,
+		}
+		requestBody, err = json.Marshal(generateRequest)
+	} else {
+		chatRequest := struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}{
+			Model: model,
+			Messages: []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			}{
+				{Role: "user", Content: prompt},
+			},
+		}
+		requestBody, err = json.Marshal(chatRequest)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("error building Ollama request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error calling Ollama API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API returned error status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if p.apiEndpoint == "generate" {
+		var result strings.Builder
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			var generateResp OllamaGenerateResponse
+			if err := json.Unmarshal(line, &generateResp); err != nil {
+				return "", fmt.Errorf("error parsing streaming response line: %v", err)
+			}
+			result.WriteString(generateResp.Response)
+			if generateResp.Done {
+				break
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("error reading streaming response: %v", err)
+		}
+		return result.String(), nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err)
+	}
+
+	var chatResp OllamaCompletionResponse
+	if err := json.Unmarshal(body, &chatResp); err == nil && chatResp.Message.Content != "" {
+		return chatResp.Message.Content, nil
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("error parsing chat response: %v", err)
+	}
+	if message, ok := result["message"].(map[string]interface{}); ok {
+		if content, ok := message["content"].(string); ok {
+			return content, nil
+		}
+	}
+	return "", fmt.Errorf("could not extract content from chat API response: %s", string(body))
+}
+
+// OpenAIProvider talks to any OpenAI-compatible /v1/chat/completions
+// endpoint (OpenAI itself, or a compatible proxy via a custom baseURL).
+type OpenAIProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+func (p *OpenAIProvider) Compress(ctx context.Context, model, prompt string) (string, error) {
+	chatRequest := struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}{
+		Model: model,
+		Messages: []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}{
+			{Role: "user", Content: prompt},
+		},
+	}
+
+	requestBody, err := json.Marshal(chatRequest)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+
+	url := strings.TrimRight(p.baseURL, "/") + "/v1/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("error building OpenAI request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error calling OpenAI API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API returned error status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return "", fmt.Errorf("error parsing OpenAI response: %v", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("could not extract content from OpenAI API response: %s", string(body))
+	}
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+// AnthropicProvider talks to the Anthropic Messages API.
+type AnthropicProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+type anthropicMessagesResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+func (p *AnthropicProvider) Compress(ctx context.Context, model, prompt string) (string, error) {
+	messagesRequest := struct {
+		Model     string `json:"model"`
+		MaxTokens int    `json:"max_tokens"`
+		Messages  []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}{
+		Model:     model,
+		MaxTokens: 4096,
+		Messages: []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}{
+			{Role: "user", Content: prompt},
+		},
+	}
+
+	requestBody, err := json.Marshal(messagesRequest)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+
+	url := strings.TrimRight(p.baseURL, "/") + "/v1/messages"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("error building Anthropic request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error calling Anthropic API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API returned error status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var messagesResp anthropicMessagesResponse
+	if err := json.Unmarshal(body, &messagesResp); err != nil {
+		return "", fmt.Errorf("error parsing Anthropic response: %v", err)
+	}
+	for _, block := range messagesResp.Content {
+		if block.Type == "text" {
+			return block.Text, nil
+		}
+	}
+	return "", fmt.Errorf("could not extract content from Anthropic API response: %s", string(body))
+}
+
+// GoogleProvider talks to the Google Gemini generateContent API.
+type GoogleProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+type geminiGenerateResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+}
+
+func (p *GoogleProvider) Compress(ctx context.Context, model, prompt string) (string, error) {
+	generateRequest := struct {
+		Contents []struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"contents"`
+	}{
+		Contents: []struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		}{
+			{Parts: []struct {
+				Text string `json:"text"`
+			}{{Text: prompt}}},
+		},
+	}
+
+	requestBody, err := json.Marshal(generateRequest)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s",
+		strings.TrimRight(p.baseURL, "/"), model, p.apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", fmt.Errorf("error building Google request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error calling Google API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API returned error status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var generateResp geminiGenerateResponse
+	if err := json.Unmarshal(body, &generateResp); err != nil {
+		return "", fmt.Errorf("error parsing Google response: %v", err)
+	}
+	if len(generateResp.Candidates) == 0 || len(generateResp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("could not extract content from Google API response: %s", string(body))
+	}
+	return generateResp.Candidates[0].Content.Parts[0].Text, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// nopCloser adapts an io.Writer that must not be closed (stdout) to
+// io.WriteCloser.
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// openOutput opens path for writing, creating its parent directory if
+// needed, or returns stdout when path is "-".
+func openOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	dir := filepath.Dir(path)
+	if dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("error creating output directory: %v", err)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %v", err)
+	}
+	return f, nil
+}
+
+// indexedResult pairs a chunk's index with its completed content for the
+// min-heap below.
+type indexedResult struct {
+	index   int
+	content string
+}
+
+// resultHeap is a min-heap of indexedResult ordered by index, used to find
+// the next in-order chunk ready to flush.
+type resultHeap []indexedResult
+
+func (h resultHeap) Len() int           { return len(h) }
+func (h resultHeap) Less(i, j int) bool { return h[i].index < h[j].index }
+func (h resultHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *resultHeap) Push(x interface{}) { *h = append(*h, x.(indexedResult)) }
+func (h *resultHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// streamWriter flushes chunk results to out strictly in index order as soon
+// as a contiguous prefix becomes available, rather than waiting for every
+// worker to finish. Results that arrive out of order are held on a min-heap
+// until the chunk in front of them closes the gap.
+type streamWriter struct {
+	out       io.Writer
+	separator string
+	pending   resultHeap
+	nextIndex int
+
+	chunksWritten int
+	bytesWritten  int64
+	start         time.Time
+}
+
+// newStreamWriter returns a streamWriter that writes to out, joining
+// successive chunks with separator (e.g. "\n\n" for prose, "" for JSONL).
+func newStreamWriter(out io.Writer, separator string) *streamWriter {
+	return &streamWriter{out: out, separator: separator, start: time.Now()}
+}
+
+// Push records a completed chunk at index and flushes any now-contiguous
+// prefix of the output.
+func (w *streamWriter) Push(index int, content string) error {
+	heap.Push(&w.pending, indexedResult{index: index, content: content})
+	return w.flush()
+}
+
+func (w *streamWriter) flush() error {
+	for len(w.pending) > 0 && w.pending[0].index == w.nextIndex {
+		item := heap.Pop(&w.pending).(indexedResult)
+
+		if w.nextIndex > 0 && w.separator != "" {
+			if _, err := io.WriteString(w.out, w.separator); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+			w.bytesWritten += int64(len(w.separator))
+		}
+		if _, err := io.WriteString(w.out, item.content); err != nil {
+			return fmt.Errorf("error writing output: %v", err)
+		}
+		w.bytesWritten += int64(len(item.content))
+		w.chunksWritten++
+		w.nextIndex++
+	}
+	return nil
+}
+
+// Throughput reports chunks/sec and bytes/sec written so far.
+func (w *streamWriter) Throughput() (chunksPerSec, bytesPerSec float64) {
+	elapsed := time.Since(w.start).Seconds()
+	if elapsed <= 0 {
+		return 0, 0
+	}
+	return float64(w.chunksWritten) / elapsed, float64(w.bytesWritten) / elapsed
+}
+
+// formatRate renders a bytes/sec figure using the largest unit that keeps
+// the number readable.
+func formatRate(bytesPerSec float64) string {
+	switch {
+	case bytesPerSec >= 1024*1024:
+		return fmt.Sprintf("%.2f MB/s", bytesPerSec/(1024*1024))
+	case bytesPerSec >= 1024:
+		return fmt.Sprintf("%.2f KB/s", bytesPerSec/1024)
+	default:
+		return fmt.Sprintf("%.0f B/s", bytesPerSec)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStreamWriterFlushesInOrder(t *testing.T) {
+	var buf strings.Builder
+	w := newStreamWriter(&buf, "|")
+
+	// Push out of order; nothing should flush until index 0 arrives, at
+	// which point the now-contiguous prefix (0,1,2) flushes together.
+	if err := w.Push(2, "c"); err != nil {
+		t.Fatalf("Push(2) error = %v", err)
+	}
+	if got := buf.String(); got != "" {
+		t.Fatalf("buffer = %q before index 0 arrives, want empty", got)
+	}
+
+	if err := w.Push(1, "b"); err != nil {
+		t.Fatalf("Push(1) error = %v", err)
+	}
+	if got := buf.String(); got != "" {
+		t.Fatalf("buffer = %q before index 0 arrives, want empty", got)
+	}
+
+	if err := w.Push(0, "a"); err != nil {
+		t.Fatalf("Push(0) error = %v", err)
+	}
+
+	want := "a|b|c"
+	if got := buf.String(); got != want {
+		t.Errorf("buffer = %q, want %q", got, want)
+	}
+	if w.chunksWritten != 3 {
+		t.Errorf("chunksWritten = %d, want 3", w.chunksWritten)
+	}
+	if w.bytesWritten != int64(len(want)) {
+		t.Errorf("bytesWritten = %d, want %d", w.bytesWritten, len(want))
+	}
+}
+
+func TestStreamWriterNoSeparatorBeforeFirstChunk(t *testing.T) {
+	var buf strings.Builder
+	w := newStreamWriter(&buf, "|")
+
+	if err := w.Push(0, "x"); err != nil {
+		t.Fatalf("Push(0) error = %v", err)
+	}
+	if got := buf.String(); got != "x" {
+		t.Errorf("buffer = %q, want %q", got, "x")
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	tests := []struct {
+		bytesPerSec float64
+		want        string
+	}{
+		{500, "500 B/s"},
+		{2048, "2.00 KB/s"},
+		{5 * 1024 * 1024, "5.00 MB/s"},
+	}
+	for _, tt := range tests {
+		if got := formatRate(tt.bytesPerSec); got != tt.want {
+			t.Errorf("formatRate(%v) = %q, want %q", tt.bytesPerSec, got, tt.want)
+		}
+	}
+}